@@ -0,0 +1,75 @@
+package levee
+
+import "math"
+
+// WithAdaptiveLimit turns on a Gradient2-style adaptive concurrency
+// limiter, generalizing the ad-hoc cap allowCall otherwise only applies
+// in HALF_OPEN into an always-on limiter that also runs in CLOSED. Instead
+// of waiting for mustOpen's SLO breach to react, it continuously estimates
+// sustainable concurrency from the breaker's own latency measurements and
+// sheds load before error rates degrade. The limit is clamped to
+// [min, max]; callers that want queued admission instead of immediate
+// rejection can layer a BulkheadPolicy in front of the breaker with With,
+// rather than configuring a queue here.
+func WithAdaptiveLimit(min, max int) Option {
+	return func(cb *CircuitBreaker) {
+		cb.adaptive = true
+		cb.minLimit = float64(min)
+		cb.maxLimit = float64(max)
+		cb.limit = float64(max)
+	}
+}
+
+// updateLimit recomputes the adaptive limit from recent latency. gradient
+// compares the short-horizon latency EWMA against the lowest long-horizon
+// EWMA observed since the last state transition (a proxy for the
+// backend's uncongested RTT): gradient near 1 means latency is close to
+// that baseline and the limit can grow, gradient at its floor of 0.5 means
+// latency has roughly doubled and the limit should shrink. queueSize adds
+// a little headroom, proportional to the current limit, so the
+// controller keeps probing for more capacity rather than settling. Must
+// be called with cb.mu held.
+func (cb *CircuitBreaker) updateLimit() {
+	shortRTT := cb.metrics.latency.MeanMid()
+	longRTT := cb.metrics.latency.MeanLong()
+
+	if shortRTT <= 0 || longRTT <= 0 {
+		return
+	}
+
+	if cb.longRTTMin == 0 || longRTT < cb.longRTTMin {
+		cb.longRTTMin = longRTT
+	}
+
+	gradient := math.Max(0.5, math.Min(1.0, cb.longRTTMin/shortRTT))
+	queueSize := math.Sqrt(cb.limit)
+
+	cb.limit = cb.limit*gradient + queueSize
+	cb.limit = math.Max(cb.minLimit, math.Min(cb.maxLimit, cb.limit))
+}
+
+// withinLimit reports whether another call may be admitted under the
+// adaptive limit, recomputing that limit only when a fresh latency window
+// has landed since the last recompute. Without this gate, updateLimit ran
+// on every admission check instead of once per sample, which let the
+// gradient settle into a degenerate fixed point untethered from how
+// often the backend actually reported fresh latency.
+func (cb *CircuitBreaker) withinLimit() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if flushes := cb.metrics.latency.Flushes(); flushes != cb.lastLimitFlush {
+		cb.lastLimitFlush = flushes
+		cb.updateLimit()
+	}
+	return float64(cb.Concurrents()) < cb.limit
+}
+
+// Limit returns the adaptive limiter's current concurrency ceiling. It is
+// only meaningful on a breaker constructed with WithAdaptiveLimit.
+func (cb *CircuitBreaker) Limit() float64 {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.limit
+}
@@ -0,0 +1,62 @@
+package levee
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimitClampsToMax(t *testing.T) {
+	cb := NewCircuitBreaker(SLO{
+		SuccessRate: 0.99,
+		Timeout:     time.Second * 5,
+	}, 100, WithAdaptiveLimit(2, 10))
+
+	if got := cb.Limit(); got != 10 {
+		t.Errorf("expected initial limit to start at max (10), got %v", got)
+	}
+}
+
+func TestAdaptiveLimitRejectsOverLimit(t *testing.T) {
+	cb := NewCircuitBreaker(SLO{
+		SuccessRate: 0.99,
+		Timeout:     time.Second * 5,
+	}, 100, WithAdaptiveLimit(1, 1))
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		cb.Call(func() error {
+			<-release
+			return nil
+		})
+		close(done)
+	}()
+
+	// Wait for the first call to register as a concurrent admission.
+	for i := 0; i < 1000 && cb.Concurrents() == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := cb.Call(func() error { return nil })
+	if err != ErrConcurrencyLimited {
+		t.Errorf("expected ErrConcurrencyLimited once at the limit, got %v", err)
+	}
+
+	close(release)
+	<-done
+}
+
+func TestAdaptiveLimitOnlyRecomputesOnFreshSample(t *testing.T) {
+	cb := NewCircuitBreaker(SLO{
+		SuccessRate: 0.99,
+		Timeout:     time.Second * 5,
+	}, 100, WithAdaptiveLimit(1, 100))
+
+	cb.withinLimit()
+	cb.withinLimit()
+	cb.withinLimit()
+
+	if got := cb.Limit(); got != 100 {
+		t.Errorf("expected limit to stay at its initial value with no latency samples yet, got %v", got)
+	}
+}
@@ -0,0 +1,70 @@
+// Package expvar implements a levee.MetricsSink backed by the standard
+// library's expvar package, so breaker telemetry shows up at /debug/vars
+// (or wherever a StatsD-style poller scrapes expvar from) without pulling
+// in a metrics client dependency.
+package expvar
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+
+	"github.com/codemartial/levee"
+)
+
+// Sink implements levee.MetricsSink by publishing every gauge, counter
+// and state event under a shared expvar.Map.
+type Sink struct {
+	vars *expvar.Map
+	mu   sync.Mutex
+}
+
+// NewSink creates a Sink and registers its backing map under name in the
+// default expvar publisher.
+func NewSink(name string) *Sink {
+	return &Sink{vars: expvar.NewMap(name)}
+}
+
+func (s *Sink) Gauge(name string, value float64, labels ...string) {
+	s.floatVar(seriesKey(name, labels)).Set(value)
+}
+
+func (s *Sink) Counter(name string, delta float64, labels ...string) {
+	s.floatVar(seriesKey(name, labels)).Add(delta)
+}
+
+func (s *Sink) Event(name string, state levee.State) {
+	s.intVar(seriesKey(name, []string{fmt.Sprintf("state%d", state)})).Add(1)
+}
+
+func (s *Sink) floatVar(key string) *expvar.Float {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v, ok := s.vars.Get(key).(*expvar.Float); ok {
+		return v
+	}
+	f := new(expvar.Float)
+	s.vars.Set(key, f)
+	return f
+}
+
+func (s *Sink) intVar(key string) *expvar.Int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v, ok := s.vars.Get(key).(*expvar.Int); ok {
+		return v
+	}
+	i := new(expvar.Int)
+	s.vars.Set(key, i)
+	return i
+}
+
+func seriesKey(name string, labels []string) string {
+	key := name
+	for _, l := range labels {
+		key += "." + l
+	}
+	return key
+}
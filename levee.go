@@ -26,7 +26,7 @@ type ICircuitBreaker interface {
 	Call(func() error) (State, error)
 	CallWithContext(context.Context, func() error) (State, error)
 	State() State
-	StateUpdates() <-chan State
+	StateUpdates() <-chan StateChange
 }
 
 type Levee struct {
@@ -76,9 +76,15 @@ func (l *Levee) CallWithContext(ctx context.Context, f func() error) (State, err
 }
 
 func (l *Levee) State() State {
+	if !l.ready {
+		return l.wu.State()
+	}
 	return l.cb.State()
 }
 
-func (l *Levee) StateUpdates() <-chan State {
+func (l *Levee) StateUpdates() <-chan StateChange {
+	if !l.ready {
+		return l.wu.StateUpdates()
+	}
 	return l.cb.StateUpdates()
 }
@@ -1,34 +1,53 @@
 package levee
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
 type CircuitBreaker struct {
-	mu          sync.Mutex
-	stated_slo  SLO
-	revised_slo SLO
-	metrics     metrics
-	concurrents int32
-	state       State
-	lastOpenAt  time.Time
+	mu            sync.Mutex
+	stated_slo    SLO
+	revised_slo   SLO
+	metrics       metrics
+	concurrents   int32
+	state         State
+	lastOpenAt    time.Time
+	sink          MetricsSink
+	bus           stateBus
+	pendingReason string
+
+	adaptive       bool
+	minLimit       float64
+	maxLimit       float64
+	limit          float64
+	longRTTMin     float64
+	lastLimitFlush uint64
 }
 
 var (
-	ErrCircuitOpen     = errors.New("circuit is open")
-	ErrCircuitHalfOpen = errors.New("circuit is half open")
+	ErrCircuitOpen        = errors.New("circuit is open")
+	ErrCircuitHalfOpen    = errors.New("circuit is half open")
+	ErrConcurrencyLimited = errors.New("concurrency limit exceeded")
 )
 
-func NewCircuitBreaker(slo SLO, size uint16) *CircuitBreaker {
-	return &CircuitBreaker{
+func NewCircuitBreaker(slo SLO, size uint16, opts ...Option) *CircuitBreaker {
+	cb := &CircuitBreaker{
 		stated_slo:  slo,
 		revised_slo: slo,
 		metrics:     *newMetrics(size),
 		state:       CLOSED,
 	}
+
+	for _, opt := range opts {
+		opt(cb)
+	}
+
+	return cb
 }
 
 func (cb *CircuitBreaker) AddConcurrent() {
@@ -60,12 +79,18 @@ func (cb *CircuitBreaker) Call(f func() error) (State, error) {
 			cb.state = HALF_OPEN
 			state = cb.state
 			cb.mu.Unlock()
+			cb.emitState(HALF_OPEN)
+			cb.bus.Publish(StateChange{From: OPEN, To: HALF_OPEN, At: time.Now(), Reason: ReasonProbeWindowOpen})
 		}
 	}
 
 	cb.AddConcurrent()
 	defer cb.RemoveConcurrent()
 
+	if cb.adaptive && !cb.withinLimit() {
+		return state, ErrConcurrencyLimited
+	}
+
 	if state == HALF_OPEN && !cb.allowCall() {
 		return state, ErrCircuitHalfOpen
 	}
@@ -96,6 +121,7 @@ func (cb *CircuitBreaker) Call(f func() error) (State, error) {
 		}
 		cb.mu.Unlock()
 	}
+	cb.publish(call_err, false)
 
 	if state == HALF_OPEN {
 		switch cb.newState() {
@@ -111,6 +137,110 @@ func (cb *CircuitBreaker) Call(f func() error) (State, error) {
 	return state, nil
 }
 
+func (cb *CircuitBreaker) CallWithContext(ctx context.Context, f func() error) (State, error) {
+	if err := ctx.Err(); err != nil {
+		return cb.State(), err
+	}
+
+	start := time.Now()
+	state := cb.State()
+
+	if state == OPEN {
+		cb.mu.Lock()
+		lastOpenAt := cb.lastOpenAt
+		timeout := cb.revised_slo.Timeout
+		cb.mu.Unlock()
+
+		if time.Since(lastOpenAt) < timeout {
+			return state, ErrCircuitOpen
+		} else {
+			cb.mu.Lock()
+			cb.state = HALF_OPEN
+			state = cb.state
+			cb.mu.Unlock()
+			cb.emitState(HALF_OPEN)
+			cb.bus.Publish(StateChange{From: OPEN, To: HALF_OPEN, At: time.Now(), Reason: ReasonProbeWindowOpen})
+		}
+	}
+
+	cb.AddConcurrent()
+	defer cb.RemoveConcurrent()
+
+	if cb.adaptive && !cb.withinLimit() {
+		return state, ErrConcurrencyLimited
+	}
+
+	if state == HALF_OPEN && !cb.allowCall() {
+		return state, ErrCircuitHalfOpen
+	}
+
+	if state == CLOSED && cb.mustOpen() {
+		return cb.OpenCircuit()
+	}
+
+	{
+		cb.mu.Lock()
+		cb.metrics.RecordConcurrency(float64(cb.Concurrents()), start)
+		cb.metrics.RecordRequests(1, start)
+		cb.mu.Unlock()
+	}
+
+	call_err := runBounded(ctx, f)
+
+	end := time.Now()
+
+	canceled := errors.Is(call_err, context.Canceled) || errors.Is(call_err, context.DeadlineExceeded)
+
+	{
+		cb.mu.Lock()
+		cb.metrics.RecordLatency(float64(end.Sub(start).Microseconds()), end)
+
+		if canceled {
+			// Upstream gave up on us; don't let that masquerade as a backend error.
+			cb.metrics.RecordCanceled(1, end)
+		} else {
+			cb.metrics.RecordCanceled(0, end)
+			if call_err != nil {
+				cb.metrics.RecordErrors(1, end)
+			} else {
+				cb.metrics.RecordErrors(0, end)
+			}
+		}
+		cb.mu.Unlock()
+	}
+	cb.publish(call_err, canceled)
+
+	if state == HALF_OPEN {
+		switch cb.newState() {
+		case OPEN:
+			return cb.OpenCircuit()
+		case CLOSED:
+			return cb.CloseCircuit()
+		default:
+			return state, call_err
+		}
+	}
+
+	return state, call_err
+}
+
+// runBounded executes f to completion but won't block past ctx's deadline:
+// f keeps running in its own goroutine, and the caller's result is whichever
+// of f() or ctx.Done() resolves first.
+func runBounded(ctx context.Context, f func() error) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- f()
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (cb *CircuitBreaker) allowCall() bool {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
@@ -142,6 +272,7 @@ func (cb *CircuitBreaker) newState() State {
 	}
 
 	if cb.metrics.errors.Mean() > (1 - cb.revised_slo.SuccessRate) {
+		cb.pendingReason = ReasonHalfOpenProbeFail
 		return OPEN
 	}
 
@@ -150,6 +281,7 @@ func (cb *CircuitBreaker) newState() State {
 		hErrors = 0.1
 	}
 	if cb.metrics.errors.FillRate()*float64(cb.metrics.errors._size) > 1/hErrors {
+		cb.pendingReason = ReasonRecovered
 		return CLOSED
 	}
 
@@ -161,6 +293,7 @@ func (cb *CircuitBreaker) mustOpen() bool {
 	defer cb.mu.Unlock()
 
 	health := 0
+	var reasons []string
 
 	var success_rate float64
 	var latency_dev float64
@@ -176,6 +309,7 @@ func (cb *CircuitBreaker) mustOpen() bool {
 	// Success Rate
 	if success_rate < cb.revised_slo.SuccessRate {
 		health += 3
+		reasons = append(reasons, ReasonSuccessRateBreach)
 	}
 
 	// If there is increased load on the system, at most two of the following
@@ -185,38 +319,59 @@ func (cb *CircuitBreaker) mustOpen() bool {
 	// Latency Anomaly
 	if latency_dev > 10*cb.metrics.latency.DeviationMid() || latency_dev > 5*cb.metrics.latency.DeviationLong() {
 		health += 1
+		reasons = append(reasons, ReasonLatencySpike)
 	}
 
 	// Concurrency Anomaly
 	if concurrency_dev > 10*cb.metrics.concurrency.DeviationMid() || concurrency_dev > 5*cb.metrics.concurrency.DeviationLong() {
 		health += 1
+		reasons = append(reasons, ReasonConcurrencySpike)
 	}
 
 	// RPS Anomaly
 	if rps > 10*cb.metrics.requests.DerivativeMid() || rps > 5*cb.metrics.requests.DerivativeLong() {
 		health += 1
+		reasons = append(reasons, ReasonRPSSpike)
+	}
+
+	if health < 3 {
+		return false
 	}
 
-	return health >= 3
+	cb.pendingReason = strings.Join(reasons, ",")
+	return true
 }
 
 func (cb *CircuitBreaker) OpenCircuit() (State, error) {
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
-
+	from := cb.state
+	reason := cb.pendingReason
+	cb.pendingReason = ""
 	cb.metrics.Reset()
+	cb.longRTTMin = 0
 	cb.state = OPEN
 	cb.lastOpenAt = time.Now()
-	return cb.state, nil
+	at := cb.lastOpenAt
+	cb.mu.Unlock()
+
+	cb.emitState(OPEN)
+	cb.bus.Publish(StateChange{From: from, To: OPEN, At: at, Reason: reason})
+	return OPEN, nil
 }
 
 func (cb *CircuitBreaker) CloseCircuit() (State, error) {
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
-
+	from := cb.state
+	reason := cb.pendingReason
+	cb.pendingReason = ""
 	cb.metrics.Reset()
+	cb.longRTTMin = 0
 	cb.state = CLOSED
-	return cb.state, nil
+	cb.mu.Unlock()
+
+	cb.emitState(CLOSED)
+	cb.bus.Publish(StateChange{From: from, To: CLOSED, At: time.Now(), Reason: reason})
+	return CLOSED, nil
 }
 
 func (cb *CircuitBreaker) State() State {
@@ -226,8 +381,43 @@ func (cb *CircuitBreaker) State() State {
 	return cb.state
 }
 
-func (cb *CircuitBreaker) StateUpdates() <-chan State {
-	return nil
+// LatencyP99Mid returns the breaker's medium-horizon p99 latency EWMA, in
+// microseconds. Policies such as HedgePolicy use it as a natural trigger
+// for hedged attempts.
+func (cb *CircuitBreaker) LatencyP99Mid() float64 {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.metrics.latency.P99Mid()
+}
+
+// StateUpdates subscribes to the breaker's state transitions. Delivery is
+// non-blocking: if the caller falls behind, further events are dropped
+// for it rather than stalling Call.
+func (cb *CircuitBreaker) StateUpdates() <-chan StateChange {
+	return cb.bus.Subscribe()
+}
+
+// UnsubscribeStateUpdates removes ch, obtained from a prior StateUpdates
+// call, from the breaker's state bus and closes it. Callers that
+// subscribe repeatedly over the breaker's lifetime (rather than once, for
+// as long as the breaker lives) should call this when done with a
+// channel to avoid leaking it.
+func (cb *CircuitBreaker) UnsubscribeStateUpdates(ch <-chan StateChange) {
+	cb.bus.Unsubscribe(ch)
+}
+
+// StateUpdateDrops reports how many state-change events have been
+// dropped for ch because the subscriber fell behind Publish.
+func (cb *CircuitBreaker) StateUpdateDrops(ch <-chan StateChange) uint64 {
+	return cb.bus.Drops(ch)
+}
+
+// OnStateChange registers f to be called, synchronously and in Call's
+// goroutine, on every state transition. Like StateUpdates, a slow f
+// should offload work rather than block, since it runs inline with Call.
+func (cb *CircuitBreaker) OnStateChange(f func(StateChange)) {
+	cb.bus.Listen(f)
 }
 
 type WarmupCB struct {
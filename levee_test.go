@@ -1,6 +1,7 @@
 package levee
 
 import (
+	"context"
 	"errors"
 	"math"
 	"testing"
@@ -199,8 +200,7 @@ func TestConcurrencyTracking(t *testing.T) {
 
 func TestEWMACalculation(t *testing.T) {
 	ts := &TimeSeries{
-		values: make([]float64, 0, 100),
-		_size:  100,
+		_size: 100,
 	}
 
 	// Record consistent values
@@ -219,4 +219,53 @@ func TestEWMACalculation(t *testing.T) {
 	}
 }
 
+func TestCallWithContextDeadline(t *testing.T) {
+	cb := NewCircuitBreaker(SLO{
+		SuccessRate: 0.99,
+		Timeout:     time.Second * 5,
+	}, 100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	slowFunc := func() error {
+		time.Sleep(time.Second)
+		return nil
+	}
+
+	start := time.Now()
+	_, err := cb.CallWithContext(ctx, slowFunc)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("CallWithContext did not return promptly on deadline, took %v", elapsed)
+	}
+}
+
+func TestCallWithContextAlreadyCanceled(t *testing.T) {
+	cb := NewCircuitBreaker(SLO{
+		SuccessRate: 0.99,
+		Timeout:     time.Second * 5,
+	}, 100)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	_, err := cb.CallWithContext(ctx, func() error {
+		called = true
+		return nil
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if called {
+		t.Error("f should not run for an already-canceled context")
+	}
+}
+
 var abs = math.Abs
@@ -0,0 +1,217 @@
+package levee
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Result carries metadata about a completed Policy execution.
+type Result struct {
+	Attempts int
+}
+
+// Policy executes f under some resilience strategy (retries, bulkheading,
+// timeouts, hedging, circuit breaking, ...) and reports how it went.
+// CircuitBreaker itself implements Policy, so it can sit at the base of a
+// chain built with With.
+type Policy interface {
+	Execute(ctx context.Context, f func() error) (Result, error)
+}
+
+// Execute adapts CallWithContext to the Policy interface, so a
+// CircuitBreaker can be used as the innermost layer of a policy chain.
+func (cb *CircuitBreaker) Execute(ctx context.Context, f func() error) (Result, error) {
+	_, err := cb.CallWithContext(ctx, f)
+	return Result{Attempts: 1}, err
+}
+
+// layer is implemented by Policy types that wrap an inner Policy; With
+// uses it to splice the chain together.
+type layer interface {
+	setNext(Policy)
+}
+
+// With composes resilience policies around a base Policy, typically a
+// CircuitBreaker. Following the convention of failsafe-go/resilience4j,
+// the first-listed layer is outermost: With(cb, retry, bulkhead) executes
+// retry first, which attempts the call against bulkhead (and, behind it,
+// cb) one or more times, with bulkhead admission applied on every attempt.
+func With(base Policy, layers ...Policy) Policy {
+	current := base
+	for i := len(layers) - 1; i >= 0; i-- {
+		l := layers[i]
+		if next, ok := l.(layer); ok {
+			next.setNext(current)
+		}
+		current = l
+	}
+	return current
+}
+
+// RetryPolicy retries a failed execution up to MaxAttempts times, with
+// exponential backoff and full jitter between attempts. RetryIf decides
+// whether a given error is worth retrying; if nil, every error is retried.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	RetryIf     func(error) bool
+
+	next Policy
+}
+
+func (p *RetryPolicy) setNext(n Policy) { p.next = n }
+
+func (p *RetryPolicy) Execute(ctx context.Context, f func() error) (Result, error) {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var res Result
+	var err error
+	delay := p.BaseDelay
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		res, err = p.next.Execute(ctx, f)
+		res.Attempts = attempt
+
+		if err == nil || attempt == attempts {
+			return res, err
+		}
+		if p.RetryIf != nil && !p.RetryIf(err) {
+			return res, err
+		}
+
+		wait := delay
+		if wait > 0 {
+			wait += time.Duration(rand.Int63n(int64(wait) + 1))
+		}
+		if p.MaxDelay > 0 && wait > p.MaxDelay {
+			wait = p.MaxDelay
+		}
+
+		select {
+		case <-ctx.Done():
+			return res, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		if delay > 0 {
+			delay *= 2
+		}
+	}
+
+	return res, err
+}
+
+// TimeoutPolicy bounds each attempt to Timeout, regardless of what the
+// caller's own context allows.
+type TimeoutPolicy struct {
+	Timeout time.Duration
+
+	next Policy
+}
+
+func (p *TimeoutPolicy) setNext(n Policy) { p.next = n }
+
+func (p *TimeoutPolicy) Execute(ctx context.Context, f func() error) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	return p.next.Execute(ctx, f)
+}
+
+// BulkheadPolicy bounds the number of concurrent executions to
+// MaxConcurrent. A call that can't get a slot waits up to QueueTimeout
+// (if positive) before giving up.
+type BulkheadPolicy struct {
+	MaxConcurrent int
+	QueueTimeout  time.Duration
+
+	next Policy
+	sem  chan struct{}
+	once sync.Once
+}
+
+func (p *BulkheadPolicy) setNext(n Policy) { p.next = n }
+
+func (p *BulkheadPolicy) Execute(ctx context.Context, f func() error) (Result, error) {
+	p.once.Do(func() {
+		p.sem = make(chan struct{}, p.MaxConcurrent)
+	})
+
+	waitCtx := ctx
+	if p.QueueTimeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, p.QueueTimeout)
+		defer cancel()
+	}
+
+	select {
+	case p.sem <- struct{}{}:
+	case <-waitCtx.Done():
+		return Result{}, waitCtx.Err()
+	}
+	defer func() { <-p.sem }()
+
+	return p.next.Execute(ctx, f)
+}
+
+// HedgePolicy fires a second, concurrent attempt if the first hasn't
+// returned within the breaker's own p99 latency (TimeSeries.P99Mid()),
+// and returns whichever attempt completes first. This only helps for
+// idempotent f.
+type HedgePolicy struct {
+	Breaker *CircuitBreaker
+
+	next Policy
+}
+
+func (p *HedgePolicy) setNext(n Policy) { p.next = n }
+
+func (p *HedgePolicy) Execute(ctx context.Context, f func() error) (Result, error) {
+	delay := time.Duration(p.Breaker.LatencyP99Mid()) * time.Microsecond
+	if delay <= 0 {
+		return p.next.Execute(ctx, f)
+	}
+
+	type attempt struct {
+		res Result
+		err error
+	}
+
+	run := func() <-chan attempt {
+		out := make(chan attempt, 1)
+		go func() {
+			res, err := p.next.Execute(ctx, f)
+			out <- attempt{res, err}
+		}()
+		return out
+	}
+
+	primary := run()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case a := <-primary:
+		return a.res, a.err
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	case <-timer.C:
+	}
+
+	hedged := run()
+
+	select {
+	case a := <-primary:
+		return a.res, a.err
+	case a := <-hedged:
+		return a.res, a.err
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
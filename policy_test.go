@@ -0,0 +1,230 @@
+package levee
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// funcPolicy adapts a plain function to Policy, for use as the innermost
+// step (the thing under test's next) in these tests.
+type funcPolicy func(ctx context.Context, f func() error) (Result, error)
+
+func (fp funcPolicy) Execute(ctx context.Context, f func() error) (Result, error) {
+	return fp(ctx, f)
+}
+
+// orderPolicy records its own name into a shared log on entry, then calls
+// through to next (or returns immediately if it's the base).
+type orderPolicy struct {
+	name string
+	log  *[]string
+
+	next Policy
+}
+
+func (p *orderPolicy) setNext(n Policy) { p.next = n }
+
+func (p *orderPolicy) Execute(ctx context.Context, f func() error) (Result, error) {
+	*p.log = append(*p.log, p.name)
+	if p.next == nil {
+		return Result{}, f()
+	}
+	return p.next.Execute(ctx, f)
+}
+
+func TestWithOrdersFirstLayerOutermost(t *testing.T) {
+	var log []string
+	base := &orderPolicy{name: "base", log: &log}
+	outer := &orderPolicy{name: "outer", log: &log}
+	inner := &orderPolicy{name: "inner", log: &log}
+
+	chain := With(base, outer, inner)
+
+	if _, err := chain.Execute(context.Background(), func() error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"outer", "inner", "base"}
+	if len(log) != len(want) {
+		t.Fatalf("expected execution order %v, got %v", want, log)
+	}
+	for i := range want {
+		if log[i] != want[i] {
+			t.Errorf("expected execution order %v, got %v", want, log)
+			break
+		}
+	}
+}
+
+var errPolicyTest = errors.New("policy test error")
+
+func TestRetryPolicyRetriesUntilSuccess(t *testing.T) {
+	var calls int32
+	p := &RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		next: funcPolicy(func(ctx context.Context, f func() error) (Result, error) {
+			return Result{}, f()
+		}),
+	}
+
+	res, err := p.Execute(context.Background(), func() error {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errPolicyTest
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("expected eventual success, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+	if res.Attempts != 3 {
+		t.Errorf("expected Result.Attempts = 3, got %d", res.Attempts)
+	}
+}
+
+func TestRetryPolicyStopsWhenRetryIfDeclines(t *testing.T) {
+	var calls int32
+	p := &RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Millisecond,
+		RetryIf:     func(error) bool { return false },
+		next: funcPolicy(func(ctx context.Context, f func() error) (Result, error) {
+			atomic.AddInt32(&calls, 1)
+			return Result{}, f()
+		}),
+	}
+
+	_, err := p.Execute(context.Background(), func() error { return errPolicyTest })
+
+	if err != errPolicyTest {
+		t.Errorf("expected errPolicyTest, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected RetryIf=false to stop after the first attempt, got %d calls", calls)
+	}
+}
+
+func TestRetryPolicyAbandonsOnContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &RetryPolicy{
+		MaxAttempts: 10,
+		BaseDelay:   time.Second,
+		next: funcPolicy(func(ctx context.Context, f func() error) (Result, error) {
+			return Result{}, f()
+		}),
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := p.Execute(ctx, func() error { return errPolicyTest })
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("expected cancellation to cut the backoff short, took %v", elapsed)
+	}
+}
+
+func TestTimeoutPolicyEnforcesDeadline(t *testing.T) {
+	p := &TimeoutPolicy{
+		Timeout: time.Millisecond * 20,
+		next: funcPolicy(func(ctx context.Context, f func() error) (Result, error) {
+			select {
+			case <-ctx.Done():
+				return Result{}, ctx.Err()
+			case <-time.After(time.Second):
+				return Result{}, nil
+			}
+		}),
+	}
+
+	start := time.Now()
+	_, err := p.Execute(context.Background(), func() error { return nil })
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("expected TimeoutPolicy's own deadline to fire before the caller's, took %v", elapsed)
+	}
+}
+
+func TestBulkheadPolicyBoundsConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	p := &BulkheadPolicy{
+		MaxConcurrent: 1,
+		QueueTimeout:  time.Millisecond * 20,
+		next: funcPolicy(func(ctx context.Context, f func() error) (Result, error) {
+			return Result{}, f()
+		}),
+	}
+
+	holding := make(chan struct{})
+	go func() {
+		p.Execute(context.Background(), func() error {
+			close(holding)
+			<-release
+			return nil
+		})
+	}()
+	<-holding
+
+	_, err := p.Execute(context.Background(), func() error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the second call to queue-timeout while the slot is held, got %v", err)
+	}
+
+	close(release)
+}
+
+func TestHedgePolicyFiresSecondAttempt(t *testing.T) {
+	cb := NewCircuitBreaker(SLO{SuccessRate: 0.99, Timeout: time.Second}, 5)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		cb.metrics.RecordLatency(5000, now.Add(time.Duration(i)*time.Millisecond)) // 5ms
+	}
+	if cb.LatencyP99Mid() <= 0 {
+		t.Fatal("expected a positive p99 latency EWMA to seed the hedge delay")
+	}
+
+	var attempts int32
+	p := &HedgePolicy{
+		Breaker: cb,
+		next: funcPolicy(func(ctx context.Context, f func() error) (Result, error) {
+			n := atomic.AddInt32(&attempts, 1)
+			if n == 1 {
+				time.Sleep(time.Second)
+				return Result{Attempts: 1}, nil
+			}
+			return Result{Attempts: 2}, nil
+		}),
+	}
+
+	start := time.Now()
+	res, err := p.Execute(context.Background(), func() error { return nil })
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if res.Attempts != 2 {
+		t.Errorf("expected the hedged (second) attempt to win, got Attempts=%d", res.Attempts)
+	}
+	if elapsed > time.Millisecond*500 {
+		t.Errorf("expected the hedge to return well before the stalled primary attempt, took %v", elapsed)
+	}
+}
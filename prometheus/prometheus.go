@@ -0,0 +1,107 @@
+// Package prometheus implements a levee.MetricsSink that accumulates
+// CircuitBreaker telemetry and serves it in the Prometheus text
+// exposition format.
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/codemartial/levee"
+)
+
+// Collector implements levee.MetricsSink and doubles as an http.Handler
+// for a Prometheus scrape endpoint. Attach it to a breaker with
+// levee.WithSink(c) and mount it with e.g. http.Handle("/metrics", c).
+type Collector struct {
+	mu       sync.RWMutex
+	gauges   map[string]float64
+	counters map[string]float64
+	events   map[string]uint64
+}
+
+// NewCollector returns a ready-to-use Collector.
+func NewCollector() *Collector {
+	return &Collector{
+		gauges:   make(map[string]float64),
+		counters: make(map[string]float64),
+		events:   make(map[string]uint64),
+	}
+}
+
+func (c *Collector) Gauge(name string, value float64, labels ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gauges[seriesKey(name, labels)] = value
+}
+
+func (c *Collector) Counter(name string, delta float64, labels ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters[seriesKey(name, labels)] += delta
+}
+
+func (c *Collector) Event(name string, state levee.State) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := seriesKey(name, []string{fmt.Sprintf(`state="%d"`, state)})
+	c.events[key]++
+}
+
+// ServeHTTP renders every tracked series in the Prometheus text
+// exposition format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeSeries(w, "gauge", c.gauges)
+	writeSeries(w, "counter", c.counters)
+
+	names := make([]string, 0, len(c.events))
+	for name := range c.events {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s_total %d\n", name, c.events[name])
+	}
+}
+
+// writeSeries emits one "# TYPE" line per metric family (the part of the
+// series key before its first "{"), followed by every labeled series that
+// belongs to it, since repeating "# TYPE" per label combination is invalid
+// exposition format.
+func writeSeries(w http.ResponseWriter, typ string, series map[string]float64) {
+	byFamily := make(map[string][]string)
+	for name := range series {
+		family := strings.SplitN(name, "{", 2)[0]
+		byFamily[family] = append(byFamily[family], name)
+	}
+
+	families := make([]string, 0, len(byFamily))
+	for family := range byFamily {
+		families = append(families, family)
+	}
+	sort.Strings(families)
+
+	for _, family := range families {
+		names := byFamily[family]
+		sort.Strings(names)
+
+		fmt.Fprintf(w, "# TYPE %s %s\n", family, typ)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s %v\n", name, series[name])
+		}
+	}
+}
+
+func seriesKey(name string, labels []string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	return name + "{" + strings.Join(labels, ",") + "}"
+}
@@ -0,0 +1,96 @@
+package levee
+
+// MetricsSink receives a CircuitBreaker's internal telemetry for export to
+// an external monitoring system. Gauge reports a point-in-time value (e.g.
+// current concurrency), Counter reports a delta to be accumulated (e.g.
+// request counts), and Event reports a state transition. Implementations
+// must be safe for concurrent use; Call and CallWithContext invoke a sink
+// from whichever goroutine is making the call.
+type MetricsSink interface {
+	Gauge(name string, value float64, labels ...string)
+	Counter(name string, delta float64, labels ...string)
+	Event(name string, state State)
+}
+
+// Option configures a CircuitBreaker at construction time.
+type Option func(*CircuitBreaker)
+
+// WithSink attaches a MetricsSink that the breaker pushes concurrency,
+// latency, error rate, anomaly-deviation and state-transition telemetry
+// through as it operates, plus per-call request/error/canceled counts.
+func WithSink(sink MetricsSink) Option {
+	return func(cb *CircuitBreaker) {
+		cb.sink = sink
+	}
+}
+
+// publish pushes a snapshot of the breaker's current metrics to its sink,
+// plus the per-call outcome (callErr, canceled) as Counter deltas. It is a
+// no-op if no sink is attached.
+func (cb *CircuitBreaker) publish(callErr error, canceled bool) {
+	if cb.sink == nil {
+		return
+	}
+
+	cb.mu.Lock()
+	concBase := cb.metrics.concurrency.MeanBase()
+	concMid := cb.metrics.concurrency.MeanMid()
+	concLong := cb.metrics.concurrency.MeanLong()
+	errRateBase := cb.metrics.errors.MeanBase()
+	errRateMid := cb.metrics.errors.MeanMid()
+	errRateLong := cb.metrics.errors.MeanLong()
+	latBase := cb.metrics.latency.MeanBase()
+	latMid := cb.metrics.latency.MeanMid()
+	latLong := cb.metrics.latency.MeanLong()
+	p99Base := cb.metrics.latency.P99Base()
+	p99Mid := cb.metrics.latency.P99Mid()
+	p99Long := cb.metrics.latency.P99Long()
+	latDev := cb.metrics.latency.Deviation()
+	latDevMid := cb.metrics.latency.DeviationMid()
+	latDevLong := cb.metrics.latency.DeviationLong()
+	concDev := cb.metrics.concurrency.Deviation()
+	concDevMid := cb.metrics.concurrency.DeviationMid()
+	concDevLong := cb.metrics.concurrency.DeviationLong()
+	rps := cb.metrics.requests.Derivative()
+	rpsMid := cb.metrics.requests.DerivativeMid()
+	rpsLong := cb.metrics.requests.DerivativeLong()
+	cb.mu.Unlock()
+
+	cb.sink.Gauge("levee_concurrency_base", concBase)
+	cb.sink.Gauge("levee_concurrency_mid", concMid)
+	cb.sink.Gauge("levee_concurrency_long", concLong)
+	cb.sink.Gauge("levee_error_rate_base", errRateBase)
+	cb.sink.Gauge("levee_error_rate_mid", errRateMid)
+	cb.sink.Gauge("levee_error_rate_long", errRateLong)
+	cb.sink.Gauge("levee_latency_ewma_base", latBase)
+	cb.sink.Gauge("levee_latency_ewma_mid", latMid)
+	cb.sink.Gauge("levee_latency_ewma_long", latLong)
+	cb.sink.Gauge("levee_latency_p99_base", p99Base)
+	cb.sink.Gauge("levee_latency_p99_mid", p99Mid)
+	cb.sink.Gauge("levee_latency_p99_long", p99Long)
+	cb.sink.Gauge("levee_latency_deviation", latDev)
+	cb.sink.Gauge("levee_latency_deviation_mid", latDevMid)
+	cb.sink.Gauge("levee_latency_deviation_long", latDevLong)
+	cb.sink.Gauge("levee_concurrency_deviation", concDev)
+	cb.sink.Gauge("levee_concurrency_deviation_mid", concDevMid)
+	cb.sink.Gauge("levee_concurrency_deviation_long", concDevLong)
+	cb.sink.Gauge("levee_rps", rps)
+	cb.sink.Gauge("levee_rps_ewma_mid", rpsMid)
+	cb.sink.Gauge("levee_rps_ewma_long", rpsLong)
+
+	cb.sink.Counter("levee_requests_total", 1)
+	switch {
+	case canceled:
+		cb.sink.Counter("levee_canceled_total", 1)
+	case callErr != nil:
+		cb.sink.Counter("levee_errors_total", 1)
+	}
+}
+
+// emitState reports a state transition to the sink, if one is attached.
+func (cb *CircuitBreaker) emitState(s State) {
+	if cb.sink == nil {
+		return
+	}
+	cb.sink.Event("levee_state", s)
+}
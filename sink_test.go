@@ -0,0 +1,97 @@
+package levee
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errTest = errors.New("test error")
+
+// fakeSink records every call made to it, for assertions in tests.
+type fakeSink struct {
+	mu       sync.Mutex
+	gauges   map[string]float64
+	counters map[string]float64
+	events   []State
+}
+
+func newFakeSink() *fakeSink {
+	return &fakeSink{
+		gauges:   make(map[string]float64),
+		counters: make(map[string]float64),
+	}
+}
+
+func (s *fakeSink) Gauge(name string, value float64, labels ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gauges[name] = value
+}
+
+func (s *fakeSink) Counter(name string, delta float64, labels ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters[name] += delta
+}
+
+func (s *fakeSink) Event(name string, state State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, state)
+}
+
+func TestWithSinkReceivesMetricsAndEvents(t *testing.T) {
+	sink := newFakeSink()
+	cb := NewCircuitBreaker(SLO{
+		SuccessRate: 0.99,
+		Timeout:     time.Second * 5,
+	}, 100, WithSink(sink))
+
+	if _, err := cb.Call(func() error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sink.mu.Lock()
+	_, ok := sink.gauges["levee_concurrency_mid"]
+	requests := sink.counters["levee_requests_total"]
+	sink.mu.Unlock()
+	if !ok {
+		t.Error("expected a levee_concurrency_mid gauge to be published after Call")
+	}
+	if requests != 1 {
+		t.Errorf("expected levee_requests_total to be incremented by 1, got %v", requests)
+	}
+
+	cb.Call(func() error { return errTest })
+
+	sink.mu.Lock()
+	errCount := sink.counters["levee_errors_total"]
+	sink.mu.Unlock()
+	if errCount != 1 {
+		t.Errorf("expected levee_errors_total to be incremented once for the failed call, got %v", errCount)
+	}
+
+	cb.OpenCircuit()
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.events) == 0 || sink.events[len(sink.events)-1] != OPEN {
+		t.Errorf("expected an OPEN event to be emitted, got %v", sink.events)
+	}
+}
+
+func TestNoSinkIsNoop(t *testing.T) {
+	cb := NewCircuitBreaker(SLO{
+		SuccessRate: 0.99,
+		Timeout:     time.Second * 5,
+	}, 100)
+
+	if _, err := cb.Call(func() error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := cb.OpenCircuit(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package levee
+
+import (
+	"sync"
+	"time"
+)
+
+// StateChange describes a single circuit breaker state transition.
+type StateChange struct {
+	From   State
+	To     State
+	At     time.Time
+	Reason string
+}
+
+// Reasons that mustOpen/newState attach to a StateChange, so listeners can
+// tell which anomaly signal (or combination of signals) drove a
+// transition without re-deriving it from raw metrics.
+const (
+	ReasonSuccessRateBreach = "success_rate_breach"
+	ReasonLatencySpike      = "latency_spike"
+	ReasonConcurrencySpike  = "concurrency_spike"
+	ReasonRPSSpike          = "rps_spike"
+	ReasonProbeWindowOpen   = "probe_window_open"
+	ReasonHalfOpenProbeFail = "half_open_probe_failed"
+	ReasonRecovered         = "recovered"
+)
+
+// stateBusBuffer is how many pending StateChanges a subscriber channel
+// holds before Publish starts dropping for it rather than blocking Call.
+const stateBusBuffer = 16
+
+// stateBus fans a CircuitBreaker's state transitions out to channel
+// subscribers and callback listeners. Delivery is non-blocking: a
+// subscriber that falls behind has events dropped for it (tracked in its
+// drop counter, readable via Drops) instead of stalling the breaker.
+type stateBus struct {
+	mu        sync.Mutex
+	subs      []chan StateChange
+	drops     map[<-chan StateChange]*uint64
+	listeners []func(StateChange)
+
+	// pubMu serializes Publish end-to-end, so two concurrent transitions
+	// (e.g. from racing Call goroutines) can't deliver to channel
+	// subscribers and callback listeners in different relative orders.
+	pubMu sync.Mutex
+}
+
+func (b *stateBus) Subscribe() <-chan StateChange {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan StateChange, stateBusBuffer)
+	b.subs = append(b.subs, ch)
+	if b.drops == nil {
+		b.drops = make(map[<-chan StateChange]*uint64)
+	}
+	b.drops[ch] = new(uint64)
+	return ch
+}
+
+// Unsubscribe removes ch from the bus and closes it, so a long-lived
+// caller that resubscribes repeatedly (e.g. across reconnects) doesn't
+// leak a channel and drop counter per call. ch must have come from
+// Subscribe on this bus; unsubscribing an unknown channel is a no-op.
+func (b *stateBus) Unsubscribe(ch <-chan StateChange) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, s := range b.subs {
+		if s == ch {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			close(s)
+			break
+		}
+	}
+	delete(b.drops, ch)
+}
+
+// Drops reports how many events have been dropped for ch because it fell
+// behind Publish. It returns 0 for a channel that was never subscribed
+// (or has since been unsubscribed).
+func (b *stateBus) Drops(ch <-chan StateChange) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	d, ok := b.drops[ch]
+	if !ok {
+		return 0
+	}
+	return *d
+}
+
+func (b *stateBus) Listen(f func(StateChange)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.listeners = append(b.listeners, f)
+}
+
+// Publish holds pubMu for its entire body so that two concurrent Publish
+// calls (e.g. from racing Call goroutines) can't interleave, keeping
+// channel subscribers and callback listeners in agreement on delivery
+// order. Within that, b.mu is held only for the channel sends (safe,
+// since every send is non-blocking) and for snapshotting listeners, since
+// b.subs/b.drops/b.listeners need it to stay consistent against a
+// concurrent Unsubscribe closing a channel and deleting its drop counter.
+// Listener callbacks then run outside b.mu: they're arbitrary caller code
+// that may legitimately call back into the bus (e.g. Unsubscribe from
+// within OnStateChange), and invoking them under b.mu would deadlock that
+// case. pubMu is a distinct lock so that reentrant call can still proceed.
+func (b *stateBus) Publish(sc StateChange) {
+	b.pubMu.Lock()
+	defer b.pubMu.Unlock()
+
+	b.mu.Lock()
+	for _, ch := range b.subs {
+		select {
+		case ch <- sc:
+		default:
+			*b.drops[ch]++
+		}
+	}
+	listeners := make([]func(StateChange), len(b.listeners))
+	copy(listeners, b.listeners)
+	b.mu.Unlock()
+
+	for _, f := range listeners {
+		f(sc)
+	}
+}
@@ -0,0 +1,69 @@
+package levee
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStateBusFanOut(t *testing.T) {
+	cb := NewCircuitBreaker(SLO{
+		SuccessRate: 0.99,
+		Timeout:     time.Second * 5,
+	}, 100)
+
+	a := cb.StateUpdates()
+	b := cb.StateUpdates()
+
+	cb.OpenCircuit()
+
+	for _, ch := range []<-chan StateChange{a, b} {
+		select {
+		case sc := <-ch:
+			if sc.To != OPEN {
+				t.Errorf("expected To=OPEN, got %v", sc.To)
+			}
+		default:
+			t.Error("expected both subscribers to receive the state change")
+		}
+	}
+}
+
+func TestStateBusDropsWhenSubscriberFallsBehind(t *testing.T) {
+	cb := NewCircuitBreaker(SLO{
+		SuccessRate: 0.99,
+		Timeout:     time.Second * 5,
+	}, 100)
+
+	ch := cb.StateUpdates()
+
+	// Fill the subscriber's buffer and then some, alternating open/close
+	// so every publish produces a distinct transition.
+	for i := 0; i < stateBusBuffer+5; i++ {
+		if i%2 == 0 {
+			cb.OpenCircuit()
+		} else {
+			cb.CloseCircuit()
+		}
+	}
+
+	if drops := cb.StateUpdateDrops(ch); drops == 0 {
+		t.Error("expected dropped events to be tracked once the subscriber fell behind")
+	}
+}
+
+func TestStateBusUnsubscribeClosesChannel(t *testing.T) {
+	cb := NewCircuitBreaker(SLO{
+		SuccessRate: 0.99,
+		Timeout:     time.Second * 5,
+	}, 100)
+
+	ch := cb.StateUpdates()
+	cb.UnsubscribeStateUpdates(ch)
+
+	cb.OpenCircuit()
+
+	_, open := <-ch
+	if open {
+		t.Error("expected channel to be closed after Unsubscribe")
+	}
+}
@@ -1,7 +1,6 @@
 package levee
 
 import (
-	"slices"
 	"sort"
 	"time"
 )
@@ -9,6 +8,11 @@ import (
 const (
 	extLo = 300   // Roughly, 5 minutes
 	extHi = 90000 // Roughly, 1 day
+
+	// digestCompression (δ) trades off the t-digest's accuracy against the
+	// number of centroids it retains: higher keeps tighter quantile
+	// estimates at the cost of more centroids.
+	digestCompression = 100
 )
 
 type EWMA struct {
@@ -17,48 +21,219 @@ type EWMA struct {
 	ewmaHi float64
 }
 
+// centroid is one weighted mean in a t-digest.
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// digest is a t-digest: a sorted, mergeable set of weighted centroids that
+// approximates the distribution of a stream of values closely enough to
+// answer quantile queries without retaining every sample. Centroids near
+// the tails are kept small (and therefore precise); centroids near the
+// median are allowed to absorb many samples.
+//
+// bit is a Fenwick tree (binary indexed tree) over centroid weights,
+// 1-indexed internally, that turns the prefix-weight lookup every Add
+// needs into an O(log n) operation instead of an O(n) scan. It's rebuilt
+// on the rare insert of a new centroid (which shifts every index after
+// it) and adjusted in place on the far more common merge/reorder path.
+type digest struct {
+	centroids []centroid
+	weight    float64
+	bit       []float64
+}
+
+// Add folds value into the digest, merging it into the nearest centroid if
+// that centroid has room under the compression bound, or inserting a new
+// singleton centroid otherwise.
+func (d *digest) Add(value float64) {
+	d.weight++
+
+	if len(d.centroids) == 0 {
+		d.centroids = []centroid{{mean: value, weight: 1}}
+		d.bit = []float64{0, 1}
+		return
+	}
+
+	i := d.nearest(value)
+	q := (d.prefixWeight(i) + d.centroids[i].weight/2) / d.weight
+	maxWeight := 4 * d.weight * q * (1 - q) / digestCompression
+
+	if d.centroids[i].weight+1 <= maxWeight {
+		c := &d.centroids[i]
+		c.mean += (value - c.mean) / (c.weight + 1)
+		c.weight++
+		d.bitAdd(i, 1)
+		d.reorder(i)
+		return
+	}
+
+	d.insert(value)
+}
+
+// nearest returns the index of the centroid whose mean is closest to value.
+func (d *digest) nearest(value float64) int {
+	i := sort.Search(len(d.centroids), func(i int) bool { return d.centroids[i].mean >= value })
+
+	switch {
+	case i == 0:
+		return 0
+	case i == len(d.centroids):
+		return i - 1
+	case value-d.centroids[i-1].mean <= d.centroids[i].mean-value:
+		return i - 1
+	default:
+		return i
+	}
+}
+
+// prefixWeight returns the total weight of every centroid preceding i, via
+// the Fenwick tree.
+func (d *digest) prefixWeight(i int) float64 {
+	var w float64
+	for ; i > 0; i -= i & (-i) {
+		w += d.bit[i]
+	}
+	return w
+}
+
+// bitAdd adds delta to the weight tracked for centroid i.
+func (d *digest) bitAdd(i int, delta float64) {
+	for i++; i < len(d.bit); i += i & (-i) {
+		d.bit[i] += delta
+	}
+}
+
+// rebuildBIT reconstructs the Fenwick tree from scratch; needed whenever
+// insert shifts centroid indices around.
+func (d *digest) rebuildBIT() {
+	d.bit = make([]float64, len(d.centroids)+1)
+	for i, c := range d.centroids {
+		d.bitAdd(i, c.weight)
+	}
+}
+
+// reorder restores sort order after updating the mean of centroid i,
+// which a merge may have nudged past one of its neighbours.
+func (d *digest) reorder(i int) {
+	for i > 0 && d.centroids[i].mean < d.centroids[i-1].mean {
+		d.swap(i, i-1)
+		i--
+	}
+	for i < len(d.centroids)-1 && d.centroids[i].mean > d.centroids[i+1].mean {
+		d.swap(i, i+1)
+		i++
+	}
+}
+
+// swap exchanges centroids i and j and keeps the Fenwick tree in sync with
+// their (now swapped) weights.
+func (d *digest) swap(i, j int) {
+	wi, wj := d.centroids[i].weight, d.centroids[j].weight
+	d.centroids[i], d.centroids[j] = d.centroids[j], d.centroids[i]
+	if wi != wj {
+		d.bitAdd(i, wj-wi)
+		d.bitAdd(j, wi-wj)
+	}
+}
+
+// insert adds value as a new singleton centroid, keeping centroids sorted.
+func (d *digest) insert(value float64) {
+	i := sort.Search(len(d.centroids), func(i int) bool { return d.centroids[i].mean >= value })
+	d.centroids = append(d.centroids, centroid{})
+	copy(d.centroids[i+1:], d.centroids[i:])
+	d.centroids[i] = centroid{mean: value, weight: 1}
+	d.rebuildBIT()
+}
+
+// Quantile walks the centroids in sorted order, accumulating weight until
+// it crosses q*weight, then linearly interpolates between the straddling
+// centroids.
+func (d *digest) Quantile(q float64) float64 {
+	if len(d.centroids) == 0 {
+		return 0
+	}
+	if len(d.centroids) == 1 {
+		return d.centroids[0].mean
+	}
+
+	target := q * d.weight
+	var cum float64
+
+	for i, c := range d.centroids {
+		if cum+c.weight >= target {
+			if i == 0 {
+				return c.mean
+			}
+			prev := d.centroids[i-1]
+			frac := (target - cum) / c.weight
+			return prev.mean + frac*(c.mean-prev.mean)
+		}
+		cum += c.weight
+	}
+
+	return d.centroids[len(d.centroids)-1].mean
+}
+
 type TimeSeries struct {
-	values  []float64
+	count   uint32
 	mean    float64
 	sumAD   float64
 	sumVT   float64
 	sumTT   float64
 	delta_t float64
 
+	sketch digest
+
 	value      *EWMA
 	p99        *EWMA
 	deviation  *EWMA
 	derivative *EWMA
 
-	_size uint16
+	_size   uint16
+	flushes uint64
 }
 
 func (s *TimeSeries) Record(value float64, t time.Time) {
-	if len(s.values) == 0 {
+	if s.count == 0 {
 		s.delta_t = float64(t.UnixMicro())
 	}
 
-	s.values = append(s.values, value)
-	s.mean = s.mean + (value-s.mean)/float64(len(s.values))
+	s.count++
+	s.mean = s.mean + (value-s.mean)/float64(s.count)
 	s.sumAD = s.sumAD + (value - s.mean)
 
 	normalized_t := float64(t.UnixMicro()) - s.delta_t
 	s.sumVT = s.sumVT + value*normalized_t
 	s.sumTT = s.sumTT + normalized_t*normalized_t
 
-	if len(s.values) == cap(s.values) && cap(s.values) > 0 {
+	s.sketch.Add(value)
+
+	if s._size > 0 && s.count == uint32(s._size) {
 		s.updateEWMAs()
-		s.values = s.values[:0]
+		s.count = 0
+		s.sketch = digest{}
+		s.flushes++
 	}
 }
 
+// Flushes returns the number of times this series has completed a window
+// and folded it into the EWMAs, i.e. how many fresh horizon-level samples
+// have landed. Callers that want to react once per sample rather than
+// once per Record (e.g. the adaptive limiter) gate on this changing.
+func (s *TimeSeries) Flushes() uint64 {
+	return s.flushes
+}
+
 func (s *TimeSeries) ResetBase() {
-	s.values = s.values[:0]
+	s.count = 0
 	s.mean = 0
 	s.sumAD = 0
 	s.sumVT = 0
 	s.sumTT = 0
 	s.delta_t = 0
+	s.sketch = digest{}
 }
 
 func (s *TimeSeries) updateEWMAs() {
@@ -77,10 +252,7 @@ func (s *TimeSeries) updateEWMAs() {
 		s.value.ewmaHi = (1-alphaHi)*s.value.ewmaHi + alphaHi*s.mean
 	}
 
-	sorted := slices.Clone(s.values)
-	sort.Float64s(sorted)
-	i_99 := int(float64(len(s.values)) * 0.99)
-	p99 := sorted[i_99]
+	p99 := s.sketch.Quantile(0.99)
 
 	if s.p99 == nil {
 		s.p99 = &EWMA{
@@ -96,14 +268,14 @@ func (s *TimeSeries) updateEWMAs() {
 
 	if s.deviation == nil {
 		s.deviation = &EWMA{
-			base:   s.sumAD / float64(len(s.values)),
-			ewmaLo: s.sumAD / float64(len(s.values)),
-			ewmaHi: s.sumAD / float64(len(s.values)),
+			base:   s.sumAD / float64(s.count),
+			ewmaLo: s.sumAD / float64(s.count),
+			ewmaHi: s.sumAD / float64(s.count),
 		}
 	} else {
-		s.deviation.base = s.sumAD / float64(len(s.values))
-		s.deviation.ewmaLo = (1-alphaLo)*s.deviation.ewmaLo + alphaLo*s.sumAD/float64(len(s.values))
-		s.deviation.ewmaHi = (1-alphaHi)*s.deviation.ewmaHi + alphaHi*s.sumAD/float64(len(s.values))
+		s.deviation.base = s.sumAD / float64(s.count)
+		s.deviation.ewmaLo = (1-alphaLo)*s.deviation.ewmaLo + alphaLo*s.sumAD/float64(s.count)
+		s.deviation.ewmaHi = (1-alphaHi)*s.deviation.ewmaHi + alphaHi*s.sumAD/float64(s.count)
 	}
 
 	derivative := s.Derivative()
@@ -122,7 +294,7 @@ func (s *TimeSeries) updateEWMAs() {
 }
 
 func (s *TimeSeries) FillRate() float64 {
-	return float64(len(s.values)) / float64(cap(s.values))
+	return float64(s.count) / float64(s._size)
 }
 
 // Use the least squares method to calculate the derivative of the series
@@ -155,8 +327,8 @@ func (s *TimeSeries) DerivativeLong() float64 {
 }
 
 func (s *TimeSeries) Mean() float64 {
-	sampleSize := float64(len(s.values))
-	if len(s.values) == 0 {
+	sampleSize := float64(s.count)
+	if s.count == 0 {
 		if s.value == nil {
 			return 0
 		}
@@ -197,6 +369,27 @@ func (s *TimeSeries) MeanLong() float64 {
 	return s.value.ewmaHi
 }
 
+// P50 returns the median of the in-progress window, read straight off the
+// t-digest without any EWMA smoothing.
+func (s *TimeSeries) P50() float64 {
+	return s.sketch.Quantile(0.50)
+}
+
+// P95 returns the 95th percentile of the in-progress window.
+func (s *TimeSeries) P95() float64 {
+	return s.sketch.Quantile(0.95)
+}
+
+// P99 returns the 99th percentile of the in-progress window.
+func (s *TimeSeries) P99() float64 {
+	return s.sketch.Quantile(0.99)
+}
+
+// P999 returns the 99.9th percentile of the in-progress window.
+func (s *TimeSeries) P999() float64 {
+	return s.sketch.Quantile(0.999)
+}
+
 func (s *TimeSeries) P99Base() float64 {
 	if s.p99 == nil {
 		return 0
@@ -219,10 +412,10 @@ func (s *TimeSeries) P99Long() float64 {
 }
 
 func (s *TimeSeries) Deviation() float64 {
-	if len(s.values) == 0 {
+	if s.count == 0 {
 		return 0
 	}
-	return s.sumAD / float64(len(s.values))
+	return s.sumAD / float64(s.count)
 }
 
 func (s *TimeSeries) DeviationBase() float64 {
@@ -251,13 +444,16 @@ type metrics struct {
 	latency     TimeSeries
 	errors      TimeSeries
 	requests    TimeSeries
+	canceled    TimeSeries
 }
 
 func newMetrics(size uint16) *metrics {
 	return &metrics{
-		concurrency: TimeSeries{values: make([]float64, 0, size), _size: size},
-		latency:     TimeSeries{values: make([]float64, 0, size), _size: size},
-		errors:      TimeSeries{values: make([]float64, 0, size), _size: size},
+		concurrency: TimeSeries{_size: size},
+		latency:     TimeSeries{_size: size},
+		errors:      TimeSeries{_size: size},
+		requests:    TimeSeries{_size: size},
+		canceled:    TimeSeries{_size: size},
 	}
 }
 
@@ -277,6 +473,10 @@ func (m *metrics) RecordRequests(requests float64, t time.Time) {
 	m.requests.Record(requests, t)
 }
 
+func (m *metrics) RecordCanceled(canceled float64, t time.Time) {
+	m.canceled.Record(canceled, t)
+}
+
 func (m *metrics) ConfidenceInterval() float64 {
 	return 0
 }
@@ -286,4 +486,5 @@ func (m *metrics) Reset() {
 	m.latency.ResetBase()
 	m.errors.ResetBase()
 	m.requests.ResetBase()
+	m.canceled.ResetBase()
 }
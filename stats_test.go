@@ -0,0 +1,118 @@
+package levee
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+// fenwickTotal sums the digest's Fenwick tree the same way prefixWeight
+// would for the full range, as an independent check that it agrees with
+// the sum of centroid weights it's meant to track.
+func fenwickTotal(d *digest) float64 {
+	return d.prefixWeight(len(d.centroids))
+}
+
+func TestDigestQuantileAccuracy(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var d digest
+	values := make([]float64, 5000)
+	for i := range values {
+		v := r.NormFloat64()*10 + 100
+		values[i] = v
+		d.Add(v)
+	}
+
+	sort.Float64s(values)
+	want := func(q float64) float64 {
+		return values[int(q*float64(len(values)-1))]
+	}
+
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		got := d.Quantile(q)
+		w := want(q)
+		if math.Abs(got-w) > 5 {
+			t.Errorf("Quantile(%v) = %v, want close to %v (sorted-array estimate)", q, got, w)
+		}
+	}
+}
+
+func TestDigestQuantileMonotonic(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	var d digest
+	for i := 0; i < 2000; i++ {
+		d.Add(r.Float64() * 1000)
+	}
+
+	prev := d.Quantile(0)
+	for _, q := range []float64{0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 1.0} {
+		got := d.Quantile(q)
+		if got < prev {
+			t.Errorf("Quantile(%v) = %v is less than Quantile at a lower q (%v)", q, got, prev)
+		}
+		prev = got
+	}
+}
+
+func TestDigestWeightConservation(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	var d digest
+
+	for i := 0; i < 3000; i++ {
+		d.Add(r.Float64() * 100)
+
+		var centroidWeight float64
+		for _, c := range d.centroids {
+			centroidWeight += c.weight
+		}
+		if centroidWeight != d.weight {
+			t.Fatalf("after %d adds: sum of centroid weights = %v, d.weight = %v", i+1, centroidWeight, d.weight)
+		}
+		if fw := fenwickTotal(&d); fw != d.weight {
+			t.Fatalf("after %d adds: Fenwick tree total = %v, d.weight = %v", i+1, fw, d.weight)
+		}
+	}
+}
+
+func TestDigestEmptyAndSingleton(t *testing.T) {
+	var d digest
+	if got := d.Quantile(0.5); got != 0 {
+		t.Errorf("Quantile on empty digest = %v, want 0", got)
+	}
+
+	d.Add(42)
+	if got := d.Quantile(0.5); got != 42 {
+		t.Errorf("Quantile on singleton digest = %v, want 42", got)
+	}
+}
+
+func TestNewMetricsSizesAllSeries(t *testing.T) {
+	m := newMetrics(123)
+
+	for name, ts := range map[string]*TimeSeries{
+		"concurrency": &m.concurrency,
+		"latency":     &m.latency,
+		"errors":      &m.errors,
+		"requests":    &m.requests,
+		"canceled":    &m.canceled,
+	} {
+		if ts._size != 123 {
+			t.Errorf("%s._size = %d, want 123", name, ts._size)
+		}
+	}
+}
+
+func TestRequestsSeriesFlushesLikeItsPeers(t *testing.T) {
+	m := newMetrics(10)
+
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		m.RecordRequests(1, now.Add(time.Duration(i)*time.Second))
+	}
+
+	if got := m.requests.Flushes(); got != 1 {
+		t.Errorf("requests.Flushes() = %d after a full window, want 1 (newMetrics must size it like the other series)", got)
+	}
+}